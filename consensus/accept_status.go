@@ -0,0 +1,24 @@
+package consensus
+
+// A BlockAcceptStatus describes what AcceptBlock actually did with a block
+// that passed validation, so callers like the miner and the net layer can
+// react appropriately without subscribing to consensus changes just to find
+// out.
+type BlockAcceptStatus int
+
+const (
+	// StatusCanonical means the block extended the current main chain
+	// directly; it is now the tip.
+	StatusCanonical BlockAcceptStatus = iota
+
+	// StatusSideChain means the block was valid but was not heavy enough to
+	// overtake the current main chain. It has been persisted as a known
+	// valid header with its transactions left unapplied, so it can be
+	// integrated quickly later if its fork ever becomes heaviest.
+	StatusSideChain
+
+	// StatusReorg means the block caused the state to switch from the
+	// current main chain to a different one, rewinding and reapplying
+	// blocks as necessary.
+	StatusReorg
+)
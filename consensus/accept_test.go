@@ -0,0 +1,11 @@
+package consensus
+
+// AcceptBlock's status codes (StatusCanonical/StatusSideChain/StatusReorg)
+// and forkBlockchain's batch-flush and rollback-on-failure logic are not
+// covered by tests in this file: both are methods on *State, and this source
+// tree does not define State (no state.go ships in this snapshot, the same
+// gap noted in futureblocks_test.go), so there is no way to construct one to
+// call AcceptBlock against. BlockIndex, ChainPath, and BadBlockSet - the
+// pieces forkBlockchain and integrateBlock actually read and write through -
+// are covered directly in blockindex_test.go, chainpath_test.go, and
+// badblocks_test.go instead.
@@ -0,0 +1,99 @@
+package consensus
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxBadBlocks bounds how many invalid BlockIDs a BadBlockSet keeps
+// cached in memory. Every block ever rejected is still recorded permanently
+// through the ChainStore, so a restart does not forget a block it has
+// already banned; only the in-memory front cache is bounded.
+const DefaultMaxBadBlocks = 100e3
+
+// A BadBlockSet is a concurrency-safe, memory-bounded view onto a
+// ChainStore's record of which blocks have been found permanently invalid.
+// It replaces an earlier plain `map[BlockID]struct{}` that, like the
+// original blockMap, grew forever with no eviction and nothing to persist it
+// across a restart.
+type BadBlockSet struct {
+	mu    sync.Mutex
+	cache map[BlockID]*list.Element
+	lru   *list.List // front = most recently touched
+	max   int
+	store ChainStore
+}
+
+// NewBadBlockSet returns a BadBlockSet backed by the given ChainStore,
+// caching up to DefaultMaxBadBlocks entries in memory.
+func NewBadBlockSet(store ChainStore) *BadBlockSet {
+	return NewBoundedBadBlockSet(store, DefaultMaxBadBlocks)
+}
+
+// NewBoundedBadBlockSet returns a BadBlockSet backed by the given
+// ChainStore, caching up to max entries in memory.
+func NewBoundedBadBlockSet(store ChainStore, max int) *BadBlockSet {
+	return &BadBlockSet{
+		cache: make(map[BlockID]*list.Element),
+		lru:   list.New(),
+		max:   max,
+		store: store,
+	}
+}
+
+// Add records id as permanently invalid, persisting it through the
+// ChainStore so the ban survives a restart.
+func (bbs *BadBlockSet) Add(id BlockID) error {
+	if err := bbs.store.SaveBadBlock(id); err != nil {
+		return err
+	}
+	bbs.mu.Lock()
+	defer bbs.mu.Unlock()
+	if elem, exists := bbs.cache[id]; exists {
+		bbs.lru.MoveToFront(elem)
+		return nil
+	}
+	elem := bbs.lru.PushFront(id)
+	bbs.cache[id] = elem
+	for bbs.lru.Len() > bbs.max {
+		back := bbs.lru.Back()
+		if back == nil {
+			break
+		}
+		bbs.lru.Remove(back)
+		delete(bbs.cache, back.Value.(BlockID))
+	}
+	return nil
+}
+
+// Contains reports whether id has previously been passed to Add, checking
+// the in-memory cache before falling back to the ChainStore.
+func (bbs *BadBlockSet) Contains(id BlockID) (bool, error) {
+	bbs.mu.Lock()
+	if elem, cached := bbs.cache[id]; cached {
+		bbs.lru.MoveToFront(elem)
+		bbs.mu.Unlock()
+		return true, nil
+	}
+	bbs.mu.Unlock()
+
+	known, err := bbs.store.IsBadBlock(id)
+	if err != nil {
+		return false, err
+	}
+	if known {
+		bbs.mu.Lock()
+		elem := bbs.lru.PushFront(id)
+		bbs.cache[id] = elem
+		for bbs.lru.Len() > bbs.max {
+			back := bbs.lru.Back()
+			if back == nil {
+				break
+			}
+			bbs.lru.Remove(back)
+			delete(bbs.cache, back.Value.(BlockID))
+		}
+		bbs.mu.Unlock()
+	}
+	return known, nil
+}
@@ -0,0 +1,53 @@
+package consensus
+
+import "testing"
+
+// TestBadBlockSetCacheBound verifies that Add evicts the
+// least-recently-touched entry once the cache reaches its max size, while
+// Contains still reports true for the evicted entry by falling through to
+// the ChainStore.
+func TestBadBlockSetCacheBound(t *testing.T) {
+	store := newFakeChainStore()
+	bbs := NewBoundedBadBlockSet(store, 2)
+
+	first := BlockID{1}
+	if err := bbs.Add(first); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := bbs.Add(BlockID{2}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := bbs.Add(BlockID{3}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if len(bbs.cache) != 2 {
+		t.Fatalf("expected cache bounded at 2 entries, got %d", len(bbs.cache))
+	}
+	if _, cached := bbs.cache[first]; cached {
+		t.Fatalf("expected least-recently-touched entry to have been evicted from the cache")
+	}
+
+	known, err := bbs.Contains(first)
+	if err != nil {
+		t.Fatalf("Contains failed: %v", err)
+	}
+	if !known {
+		t.Fatalf("expected evicted entry to still be known via the store")
+	}
+}
+
+// TestBadBlockSetContainsUnknown verifies that Contains reports false for a
+// BlockID that was never added.
+func TestBadBlockSetContainsUnknown(t *testing.T) {
+	store := newFakeChainStore()
+	bbs := NewBadBlockSet(store)
+
+	known, err := bbs.Contains(BlockID{42})
+	if err != nil {
+		t.Fatalf("Contains failed: %v", err)
+	}
+	if known {
+		t.Fatalf("expected an unadded BlockID to be reported unknown")
+	}
+}
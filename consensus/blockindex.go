@@ -0,0 +1,136 @@
+package consensus
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxIndexNodes bounds how many BlockNodes a BlockIndex keeps cached
+// in memory at once. Every node is still persisted through the ChainStore
+// regardless of this bound; it only limits the size of the in-memory cache
+// sitting in front of it, the same way DefaultMaxOrphans bounds OrphanManager.
+const DefaultMaxIndexNodes = 100e3
+
+// indexEntry is the value stored in a BlockIndex's LRU list.
+type indexEntry struct {
+	id   BlockID
+	node *BlockNode
+}
+
+// A BlockIndex is a concurrency-safe, memory-bounded view onto a ChainStore.
+// It keeps up to maxNodes recently-touched BlockNodes cached in memory,
+// evicting the least-recently-touched one once that cap is reached, and
+// falls back to the store for everything else - so the State no longer
+// needs to hold every block header it has ever seen in an unbounded map.
+type BlockIndex struct {
+	mu       sync.Mutex
+	nodes    map[BlockID]*list.Element
+	lru      *list.List // front = most recently touched
+	maxNodes int
+	store    ChainStore
+}
+
+// NewBlockIndex returns a BlockIndex backed by the given ChainStore, caching
+// up to DefaultMaxIndexNodes nodes in memory.
+func NewBlockIndex(store ChainStore) *BlockIndex {
+	return NewBoundedBlockIndex(store, DefaultMaxIndexNodes)
+}
+
+// NewBoundedBlockIndex returns a BlockIndex backed by the given ChainStore,
+// caching up to maxNodes nodes in memory.
+func NewBoundedBlockIndex(store ChainStore, maxNodes int) *BlockIndex {
+	return &BlockIndex{
+		nodes:    make(map[BlockID]*list.Element),
+		lru:      list.New(),
+		maxNodes: maxNodes,
+		store:    store,
+	}
+}
+
+// Node returns the BlockNode for the given BlockID, checking the in-memory
+// cache before falling back to the ChainStore.
+func (bi *BlockIndex) Node(id BlockID) (*BlockNode, error) {
+	bi.mu.Lock()
+	if elem, cached := bi.nodes[id]; cached {
+		bi.lru.MoveToFront(elem)
+		bn := elem.Value.(*indexEntry).node
+		bi.mu.Unlock()
+		return bn, nil
+	}
+	bi.mu.Unlock()
+
+	bn, err := bi.store.LoadNode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	bi.mu.Lock()
+	bi.cacheLocked(id, bn)
+	bi.mu.Unlock()
+	return bn, nil
+}
+
+// AddNode persists the BlockNode for id and caches it in memory.
+func (bi *BlockIndex) AddNode(id BlockID, bn *BlockNode) error {
+	if err := bi.store.SaveNode(id, bn); err != nil {
+		return err
+	}
+	bi.mu.Lock()
+	bi.cacheLocked(id, bn)
+	bi.mu.Unlock()
+	return nil
+}
+
+// cacheLocked inserts or refreshes id's entry at the front of the LRU,
+// evicting the least-recently-touched entry if this pushes the cache over
+// maxNodes. Callers must hold bi.mu.
+func (bi *BlockIndex) cacheLocked(id BlockID, bn *BlockNode) {
+	if elem, exists := bi.nodes[id]; exists {
+		elem.Value.(*indexEntry).node = bn
+		bi.lru.MoveToFront(elem)
+		return
+	}
+	elem := bi.lru.PushFront(&indexEntry{id: id, node: bn})
+	bi.nodes[id] = elem
+	for bi.lru.Len() > bi.maxNodes {
+		back := bi.lru.Back()
+		if back == nil {
+			break
+		}
+		bi.lru.Remove(back)
+		delete(bi.nodes, back.Value.(*indexEntry).id)
+	}
+}
+
+// RemoveNode evicts the BlockNode for id from the cache and the store, e.g.
+// because the block it corresponds to has been invalidated.
+func (bi *BlockIndex) RemoveNode(id BlockID) error {
+	bi.mu.Lock()
+	if elem, exists := bi.nodes[id]; exists {
+		bi.lru.Remove(elem)
+		delete(bi.nodes, id)
+	}
+	bi.mu.Unlock()
+	return bi.store.RemoveNode(id)
+}
+
+// Block returns the full Block for the given BlockID. Full blocks are not
+// cached in memory; they are read through to the ChainStore every time.
+func (bi *BlockIndex) Block(id BlockID) (Block, error) {
+	return bi.store.LoadBlock(id)
+}
+
+// AddBlock persists the full body of a block.
+func (bi *BlockIndex) AddBlock(b Block) error {
+	return bi.store.SaveBlock(b)
+}
+
+// SetTip records id as the current main-chain tip.
+func (bi *BlockIndex) SetTip(id BlockID) error {
+	return bi.store.SaveTip(id)
+}
+
+// Tip returns the most recently recorded main-chain tip.
+func (bi *BlockIndex) Tip() (BlockID, error) {
+	return bi.store.LoadTip()
+}
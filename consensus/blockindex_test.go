@@ -0,0 +1,58 @@
+package consensus
+
+import "testing"
+
+// TestBlockIndexCacheBound verifies that AddNode evicts the
+// least-recently-touched node once the cache reaches its max size, while the
+// evicted node remains retrievable by falling through to the ChainStore.
+func TestBlockIndexCacheBound(t *testing.T) {
+	store := newFakeChainStore()
+	bi := NewBoundedBlockIndex(store, 2)
+
+	first := BlockID{1}
+	if err := bi.AddNode(first, &BlockNode{Height: 0}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	if err := bi.AddNode(BlockID{2}, &BlockNode{Height: 1}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	if err := bi.AddNode(BlockID{3}, &BlockNode{Height: 2}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+
+	if len(bi.nodes) != 2 {
+		t.Fatalf("expected cache bounded at 2 entries, got %d", len(bi.nodes))
+	}
+	if _, cached := bi.nodes[first]; cached {
+		t.Fatalf("expected least-recently-touched node to have been evicted from the cache")
+	}
+
+	// The evicted node is still persisted, so Node() falls through to the
+	// store and finds it.
+	bn, err := bi.Node(first)
+	if err != nil {
+		t.Fatalf("expected evicted node to still be loadable from the store, got %v", err)
+	}
+	if bn.Height != 0 {
+		t.Fatalf("expected loaded node to match what was saved, got height %d", bn.Height)
+	}
+}
+
+// TestBlockIndexRemoveNode verifies that RemoveNode clears both the cache
+// entry and the underlying ChainStore record.
+func TestBlockIndexRemoveNode(t *testing.T) {
+	store := newFakeChainStore()
+	bi := NewBlockIndex(store)
+	id := BlockID{7}
+	if err := bi.AddNode(id, &BlockNode{Height: 3}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+
+	if err := bi.RemoveNode(id); err != nil {
+		t.Fatalf("RemoveNode failed: %v", err)
+	}
+
+	if _, err := bi.Node(id); err == nil {
+		t.Fatalf("expected Node to fail for a removed node")
+	}
+}
@@ -0,0 +1,22 @@
+package consensus
+
+// A BlockNode is the unit stored in the BlockIndex. Earlier versions of this
+// package embedded the full Block (including every transaction) and a
+// RecentTimestamps array inside every node that was ever seen, which meant
+// the entire known chain had to live in RAM and be rebuilt from scratch on
+// every restart. BlockNode is intentionally slim: it carries just enough
+// information to walk the chain and recompute weight/timestamp rules. The
+// full Block body is fetched from the ChainStore on the rare occasions it is
+// actually needed (e.g. applying its transactions).
+type BlockNode struct {
+	ParentBlockID BlockID
+	Height        BlockHeight
+	Target        Target
+	Depth         Target
+	Timestamp     Timestamp
+
+	// Diff is nil until the block has been applied to the consensus state.
+	// Side chains therefore carry a nil Diff right up until the moment they
+	// become the main chain and forkBlockchain() integrates them.
+	Diff *BlockDiff
+}
@@ -1,88 +1,39 @@
 package consensus
 
 import (
-	"errors"
+	"fmt"
 	"math/big"
-	"sort"
 	"time"
 
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/hash"
 )
 
-// A non-consensus rule that dictates how much heavier a competing chain has to
-// be before the node will switch to mining on that chain. It is set to 5%,
-// which actually means that the heavier chain needs to be heavier by 5% of
-// _one block_, not 5% heavier as a whole.
-//
-// This rule is in place because the difficulty gets updated every block, and
-// that means that of two competing blocks, one could be very slightly heavier.
-// The slightly heavier one should not be switched to if it was not seen first,
-// because the amount of extra weight in the chain is inconsequential. The
-// maximum difficulty shift will prevent people from manipulating timestamps
-// enough to produce a block that is substantially heavier, thus making 5% an
-// acceptible value.
-var SurpassThreshold = big.NewRat(5, 100)
-
-// Exported Errors
-var (
-	BlockKnownErr    = errors.New("block exists in block map.")
-	FutureBlockErr   = errors.New("timestamp too far in future, will try again later.")
-	KnownOrphanErr   = errors.New("block is a known orphan")
-	UnknownOrphanErr = errors.New("block is an unknown orphan")
-)
-
-// earliestChildTimestamp() returns the earliest timestamp that a child node
-// can have while still being valid. See section 'Timestamp Rules' in
-// Consensus.md.
-//
-// TODO: Rather than having the blocknode store the timestamps, blocknodes
-// should just point to their parent block, and this function should just crawl
-// through the parents.
-//
-// TODO: After changing how the timestamps are aquired, write some tests to
-// check that the timestamp code is working right.
-func (bn *BlockNode) earliestChildTimestamp() Timestamp {
-	// Get the MedianTimestampWindow previous timestamps and sort them. For
-	// now, bn.RecentTimestamps is expected to have the correct timestamps.
-	var intTimestamps []int
-	for _, timestamp := range bn.RecentTimestamps {
-		intTimestamps = append(intTimestamps, int(timestamp))
-	}
-	sort.Ints(intTimestamps)
-
-	// Return the median of the sorted timestamps.
-	return Timestamp(intTimestamps[MedianTimestampWindow/2])
-}
-
-// handleOrphanBlock adds a block to the list of orphans, returning an error
-// indicating whether the orphan existed previously or not. handleOrphanBlock
-// always returns an error.
+// handleOrphanBlock hands a block off to the OrphanManager, returning an
+// error indicating whether the orphan existed previously or not.
+// handleOrphanBlock always returns an error.
 func (s *State) handleOrphanBlock(b Block) error {
 	// Sanity check that the function is being used correctly.
 	if DEBUG {
-		_, exists := s.blockMap[b.ParentBlockID]
-		if exists {
+		_, err := s.index.Node(b.ParentBlockID)
+		if err == nil {
 			panic("Incorrect use of handleOrphanBlock")
 		}
 	}
 
-	// Check if the missing parent is unknown
-	missingParent, exists := s.missingParents[b.ParentBlockID]
-	if !exists {
-		// Add an entry for the parent and add the orphan block to the entry.
-		s.missingParents[b.ParentBlockID] = make(map[BlockID]Block)
-		s.missingParents[b.ParentBlockID][b.ID()] = b
-		return UnknownOrphanErr
-	}
+	return s.orphans.AddOrphan(b)
+}
 
-	// Check if the orphan is already known, and add the orphan if not.
-	_, exists = missingParent[b.ID()]
-	if exists {
-		return KnownOrphanErr
+// promoteOrphans drains every orphan waiting on parentID out of the
+// OrphanManager and resubmits it to AcceptBlock, now that its parent has
+// been integrated. A promoted orphan may itself be the missing parent of
+// further orphans, so this recurses down through AcceptBlock -> promoteOrphans
+// until the whole waiting subtree has been given a chance to be accepted.
+func (s *State) promoteOrphans(parentID BlockID) {
+	for _, child := range s.orphans.GetOrphansOfParent(parentID) {
+		s.orphans.markPromoted(child.ID())
+		s.AcceptBlock(child)
 	}
-	missingParent[b.ID()] = b
-	return UnknownOrphanErr
 }
 
 // checkDestiny determines if the blocks destiny is already known within the
@@ -91,64 +42,84 @@ func (s *State) handleOrphanBlock(b Block) error {
 // been seen before.
 func (s *State) checkDestiny(b Block) (err error) {
 	// See if the block is a known invalid block.
-	_, exists := s.badBlocks[b.ID()]
-	if exists {
-		err = errors.New("block is known to be invalid")
+	known, err := s.badBlocks.Contains(b.ID())
+	if err != nil {
+		return
+	}
+	if known {
+		err = ErrKnownBadBlock
 		return
 	}
 
 	// See if the block is valid block.
-	_, exists = s.blockMap[b.ID()]
-	if exists {
-		err = BlockKnownErr
+	_, err = s.index.Node(b.ID())
+	if err == nil {
+		err = ErrBlockKnown
 		return
 	}
 
 	// See if the block is an orphan.
-	_, exists = s.blockMap[b.ParentBlockID]
-	if !exists {
+	_, err = s.index.Node(b.ParentBlockID)
+	if err != nil {
 		err = s.handleOrphanBlock(b)
 		return
 	}
 	return
 }
 
+// markBad records id as permanently invalid because of cause, and returns
+// the error that should actually be surfaced to the caller. If the
+// ChainStore write behind badBlocks itself fails, that failure is folded
+// into the returned error rather than discarded - cause is still the real
+// reason the block was rejected, but a caller that only checked for nil
+// deserves to know the rejection may not have been durably recorded too.
+func (s *State) markBad(id BlockID, cause error) error {
+	if err := s.badBlocks.Add(id); err != nil {
+		return fmt.Errorf("%v (also failed to record %v as a bad block: %v)", cause, id, err)
+	}
+	return cause
+}
+
 // State.validateHaeader() returns err = nil if the header information in the
 // block (everything except the transactions) is valid, and returns an error
-// explaining why validation failed if the header is invalid.
+// explaining why validation failed if the header is invalid. The actual
+// consensus rules live behind s.validator, a BlockValidator, so that they can
+// be swapped out or exercised without a full State; this method is left
+// holding only the parts that are specific to running State - the
+// future-block queue - plus blacklisting whatever the validator rejects for
+// a permanent reason.
 func (s *State) validateHeader(parent *BlockNode, b *Block) (err error) {
 	// Check the id meets the target.
-	if !b.CheckTarget(parent.Target) {
-		err = errors.New("block does not meet target")
+	if err = s.validator.VerifyPoW(b, parent.Target); err != nil {
+		err = s.markBad(b.ID(), err)
 		return
 	}
 
-	// Check that the block is not too far in the future.
-	// TODO: sleep for 30 seconds at a time
+	// Check that the block is not too far in the future. Blocks that are
+	// merely ahead of FutureThreshold are queued and retried later by the
+	// futureBlockReaper; blocks beyond MaxTimeFutureBlocks are rejected
+	// outright rather than held onto indefinitely.
 	skew := b.Timestamp - Timestamp(time.Now().Unix())
-	if skew > FutureThreshold {
-		go func(skew Timestamp, b Block) {
-			time.Sleep(time.Duration(skew-FutureThreshold) * time.Second)
-			// s.Lock()
-			s.AcceptBlock(b)
-			// s.Unlock()
-		}(skew, *b)
-		err = FutureBlockErr
+	if skew > s.config.FutureThreshold {
+		if skew > MaxTimeFutureBlocks {
+			err = ErrFutureBlock
+			return
+		}
+		s.futureBlocks.Add(*b)
+		err = ErrFutureBlock
 		return
 	}
 
 	// If timestamp is too far in the past, reject and put in bad blocks.
-	if parent.earliestChildTimestamp() > b.Timestamp {
-		s.badBlocks[b.ID()] = struct{}{}
-		err = errors.New("timestamp invalid for being in the past")
+	if err = s.validator.ValidateHeader(parent, b); err != nil {
+		err = s.markBad(b.ID(), err)
 		return
 	}
 
 	// Check that the transaction merkle root matches the transactions
 	// included into the block.
-	if b.MerkleRoot != b.TransactionMerkleRoot() {
-		s.badBlocks[b.ID()] = struct{}{}
-		err = errors.New("merkle root does not match transactions sent.")
+	if err = s.validator.ValidateBody(b); err != nil {
+		err = s.markBad(b.ID(), err)
 		return
 	}
 
@@ -157,33 +128,37 @@ func (s *State) validateHeader(parent *BlockNode, b *Block) (err error) {
 
 // State.childTarget() calculates the proper target of a child node given the
 // parent node, and copies the target into the child node.
-func (s *State) childTarget(parentNode *BlockNode, newNode *BlockNode) Target {
+func (s *State) childTarget(parentNode *BlockNode, newNode *BlockNode, newBlock *Block) Target {
 	var timePassed, expectedTimePassed Timestamp
-	if newNode.Height < TargetWindow {
-		timePassed = newNode.Block.Timestamp - s.blockRoot.Block.Timestamp
-		expectedTimePassed = BlockFrequency * Timestamp(newNode.Height)
+	if newNode.Height < s.config.TargetWindow {
+		genesisBlock, err := s.BlockAtHeight(0)
+		if err != nil {
+			panic(err)
+		}
+		timePassed = newBlock.Timestamp - genesisBlock.Timestamp
+		expectedTimePassed = s.config.BlockFrequency * Timestamp(newNode.Height)
 	} else {
 		// THIS CODE ASSUMES THAT THE BLOCK AT HEIGHT
 		// NEWNODE.HEIGHT-TARGETWINDOW IS THE SAME FOR BOTH THE NEW NODE AND
 		// THE CURRENT FORK. IN GENERAL THIS IS A PRETTY SAFE ASSUMPTION AS ITS
 		// LOOKING BACKWARDS BY 5000 BLOCKS. BUT WE SHOULD PROBABLY IMPLEMENT
 		// SOMETHING THATS FULLY SAFE REGARDLESS.
-		adjustmentBlock, err := s.BlockAtHeight(newNode.Height - TargetWindow)
+		adjustmentBlock, err := s.BlockAtHeight(newNode.Height - s.config.TargetWindow)
 		if err != nil {
 			panic(err)
 		}
-		timePassed = newNode.Block.Timestamp - adjustmentBlock.Timestamp
-		expectedTimePassed = BlockFrequency * Timestamp(TargetWindow)
+		timePassed = newBlock.Timestamp - adjustmentBlock.Timestamp
+		expectedTimePassed = s.config.BlockFrequency * Timestamp(s.config.TargetWindow)
 	}
 
 	// Adjustment = timePassed / expectedTimePassed.
 	targetAdjustment := big.NewRat(int64(timePassed), int64(expectedTimePassed))
 
 	// Enforce a maximum targetAdjustment
-	if targetAdjustment.Cmp(MaxAdjustmentUp) == 1 {
-		targetAdjustment = MaxAdjustmentUp
-	} else if targetAdjustment.Cmp(MaxAdjustmentDown) == -1 {
-		targetAdjustment = MaxAdjustmentDown
+	if targetAdjustment.Cmp(s.config.MaxAdjustmentUp) == 1 {
+		targetAdjustment = s.config.MaxAdjustmentUp
+	} else if targetAdjustment.Cmp(s.config.MaxAdjustmentDown) == -1 {
+		targetAdjustment = s.config.MaxAdjustmentDown
 	}
 
 	newTarget := new(big.Rat).Mul(parentNode.Target.Rat(), targetAdjustment)
@@ -199,32 +174,34 @@ func (s *State) childDepth(parentNode *BlockNode) (depth Target) {
 }
 
 // State.addBlockToTree() takes a block and a parent node, and adds a child
-// node to the parent containing the block. No validation is done.
-func (s *State) addBlockToTree(parentNode *BlockNode, b *Block) (newNode *BlockNode) {
+// node to the index containing the block. No validation is done.
+func (s *State) addBlockToTree(parentNode *BlockNode, b *Block) (newNode *BlockNode, err error) {
 	// Create the child node.
-	newNode = new(BlockNode)
-	newNode.Block = b
-	newNode.Height = parentNode.Height + 1
-
-	// Copy over the timestamps.
-	copy(newNode.RecentTimestamps[:], parentNode.RecentTimestamps[1:])
-	newNode.RecentTimestamps[10] = b.Timestamp
+	newNode = &BlockNode{
+		ParentBlockID: b.ParentBlockID,
+		Height:        parentNode.Height + 1,
+		Timestamp:     b.Timestamp,
+	}
 
 	// Calculate target and depth.
-	newNode.Target = s.childTarget(parentNode, newNode)
+	newNode.Target = s.childTarget(parentNode, newNode, b)
 	newNode.Depth = s.childDepth(parentNode)
 
-	// Add the node to the block map and the list of its parents children.
-	s.blockMap[b.ID()] = newNode
-	parentNode.Children = append(parentNode.Children, newNode)
+	// Persist the block and its node through the index.
+	if err = s.index.AddBlock(*b); err != nil {
+		return nil, err
+	}
+	if err = s.index.AddNode(b.ID(), newNode); err != nil {
+		return nil, err
+	}
 
-	return
+	return newNode, nil
 }
 
 // State.heavierFork() returns true if the input node is 5% heavier than the
 // current node of the ConsensusState.
 func (s *State) heavierFork(newNode *BlockNode) bool {
-	threshold := new(big.Rat).Mul(s.CurrentBlockWeight(), SurpassThreshold)
+	threshold := new(big.Rat).Mul(s.CurrentBlockWeight(), s.config.SurpassThreshold)
 	currentCumDiff := s.Depth().Inverse()
 	requiredCumDiff := new(big.Rat).Add(currentCumDiff, threshold)
 	newNodeCumDiff := newNode.Depth.Inverse()
@@ -233,15 +210,20 @@ func (s *State) heavierFork(newNode *BlockNode) bool {
 
 // State.rewindABlock() removes the most recent block from the ConsensusState,
 // making the ConsensusState as though the block had never been integrated.
-func (s *State) invertRecentBlock() (diffs []OutputDiff) {
+// Unlike the Output lookup below (a genuine invariant - the subsidy output
+// for the current block must exist), the currentPath removal at the end can
+// fail on an ordinary transient ChainStore I/O error. invertRecentBlock runs
+// in a loop on every reorg, so that failure is returned rather than panicked
+// on, the same way integrateBlock propagates its own ChainStore errors.
+func (s *State) invertRecentBlock() (diffs []OutputDiff, err error) {
 	// Remove the output for the miner subsidy.
 	//
 	// TODO: Update this for incentive stuff - miner doesn't get subsidy until
 	// 2000 or 5000 or 10000 blocks later.
 	subsidyID := s.CurrentBlock().SubsidyID()
-	subsidy, err := s.Output(subsidyID)
-	if err != nil {
-		panic(err)
+	subsidy, outputErr := s.Output(subsidyID)
+	if outputErr != nil {
+		panic(outputErr)
 	}
 	diff := OutputDiff{New: false, ID: subsidyID, Output: subsidy}
 	diffs = append(diffs, diff)
@@ -259,7 +241,9 @@ func (s *State) invertRecentBlock() (diffs []OutputDiff) {
 	}
 
 	// Update the CurrentBlock and CurrentPath variables of the longest fork.
-	delete(s.currentPath, s.Height())
+	if err = s.currentPath.Remove(s.Height()); err != nil {
+		return
+	}
 	s.currentBlockID = s.CurrentBlock().ParentBlockID
 	return
 }
@@ -301,10 +285,32 @@ func (s *State) integrateBlock(b Block, bd *BlockDiff) (diffs []OutputDiff, err
 	diffSet := s.applyContractMaintenance(&bd.BlockChanges)
 	diffs = append(diffs, diffSet...)
 
-	// Update the current block and current path variables of the longest fork.
-	height := s.blockMap[b.ID()].Height
+	// Update the current block and current path variables of the longest
+	// fork. s.index is backed by a ChainStore, so these can now fail on a
+	// transient I/O error instead of the pure map writes they used to be;
+	// propagate the error up through integrateBlock's own return value
+	// rather than panicking and taking the whole node down with it. Every
+	// ChainStore write below has to succeed before s.currentBlockID moves
+	// forward, and node.Diff is set on a copy rather than in place - Node()
+	// hands back the exact pointer BlockIndex keeps cached, so mutating it
+	// directly would make the cache visibly out of sync with the store the
+	// instant this ran, even if AddNode went on to fail.
+	node, err := s.index.Node(b.ID())
+	if err != nil {
+		return
+	}
+	if err = s.currentPath.Set(node.Height, b.ID()); err != nil {
+		return
+	}
+	updatedNode := *node
+	updatedNode.Diff = bd
+	if err = s.index.AddNode(b.ID(), &updatedNode); err != nil {
+		return
+	}
+	if err = s.index.SetTip(b.ID()); err != nil {
+		return
+	}
 	s.currentBlockID = b.ID()
-	s.currentPath[height] = b.ID()
 
 	// Add coin inflation to the miner subsidy.
 	minerSubsidy += CalculateCoinbase(s.Height())
@@ -324,35 +330,89 @@ func (s *State) integrateBlock(b Block, bd *BlockDiff) (diffs []OutputDiff, err
 	return
 }
 
-// invalidateNode() is a recursive function that deletes all of the
-// children of a block and puts them on the bad blocks list.
-func (s *State) invalidateNode(node *BlockNode) {
-	for i := range node.Children {
-		s.invalidateNode(node.Children[i])
+// invalidateNode() removes a block from the index and puts it on the bad
+// blocks list, returning whichever of those two ChainStore-backed writes
+// fails first so a caller can fold it into the error it is already
+// returning rather than silently losing it.
+//
+// TODO: BlockNode no longer carries forward links to its children, so a
+// descendant of id that was already indexed (e.g. a side chain built on top
+// of a block that just failed validation) is not evicted here. It will be
+// caught and rejected on its own the next time something tries to build on
+// top of it, since its parent will no longer resolve through the index.
+func (s *State) invalidateNode(id BlockID) error {
+	if err := s.index.RemoveNode(id); err != nil {
+		return err
 	}
-
-	delete(s.blockMap, node.Block.ID())
-	s.badBlocks[node.Block.ID()] = struct{}{}
+	return s.badBlocks.Add(id)
 }
 
+// reorgBatchSize bounds how many blocks' worth of BlockDiffs forkBlockchain
+// assembles into a single ConsensusChange before flushing it to subscribers.
+// Without this, a deep reorg (thousands of blocks) would have to hold every
+// intervening diff in memory at once just to build one notification.
+const reorgBatchSize = 500
+
 // forkBlockchain() will go from the current block over to a block on a
 // different fork, rewinding and integrating blocks as needed. forkBlockchain()
-// will return an error if any of the blocks in the new fork are invalid.
-func (s *State) forkBlockchain(newNode *BlockNode) (rewoundBlocks []Block, appliedBlocks []Block, outputDiffs []OutputDiff, err error) {
-	// Create a block diff for use when calling integrateBlock.
-	var cc ConsensusChange
-
+// will return an error if any of the blocks in the new fork are invalid, and
+// otherwise returns how many blocks were rewound and applied so the caller
+// can tell a reorg from a direct extension.
+//
+// Every block on the new fork has already passed header/PoW/size validation
+// by the time it reaches here (addBlockToTree persists side chains as known
+// valid headers as soon as they are seen) - the only work left to do is the
+// state-transition work of integrateBlock, applied in reorgBatchSize chunks
+// so that subscribers hear about a long reorg incrementally rather than all
+// at once.
+//
+// forkBlockchain tracks the blocks it rewinds and applies as BlockIDs rather
+// than full Blocks: a deep reorg can touch thousands of blocks, and a Block's
+// embedded Transactions make the full-Block slices this used to keep
+// unboundedly expensive to hold in memory for the duration of one call. The
+// full Block is only ever needed again to reintegrate a rewound block (if
+// the new fork turns out invalid) or to integrate a block on the new fork,
+// and both of those already have to fetch it from the index/ChainStore
+// on-the-fly. The OutputDiffs produced along the way are not accumulated
+// here either, since each BlockDiff delivered to subscribers already carries
+// its own.
+func (s *State) forkBlockchain(newNodeID BlockID, newNode *BlockNode) (rewoundCount int, appliedCount int, err error) {
 	// Find the common parent between the new fork and the current
 	// fork, keeping track of which path is taken through the
 	// children of the parents so that we can re-trace as we
 	// validate the blocks.
+	currentID := newNodeID
 	currentNode := newNode
-	value := s.currentPath[currentNode.Height]
 	var parentHistory []BlockID
-	for value != currentNode.Block.ID() {
-		parentHistory = append(parentHistory, currentNode.Block.ID())
-		currentNode = s.blockMap[currentNode.Block.ParentBlockID]
-		value = s.currentPath[currentNode.Height]
+	// The current path has no entry above the current height, so walk down to
+	// that height before comparing against it.
+	for currentNode.Height > s.Height() {
+		parentHistory = append(parentHistory, currentID)
+		parentNode, nodeErr := s.index.Node(currentNode.ParentBlockID)
+		if nodeErr != nil {
+			err = nodeErr
+			return
+		}
+		currentID = currentNode.ParentBlockID
+		currentNode = parentNode
+	}
+	value, err := s.currentPath.Get(currentNode.Height)
+	if err != nil {
+		return
+	}
+	for value != currentID {
+		parentHistory = append(parentHistory, currentID)
+		parentNode, nodeErr := s.index.Node(currentNode.ParentBlockID)
+		if nodeErr != nil {
+			err = nodeErr
+			return
+		}
+		currentID = currentNode.ParentBlockID
+		currentNode = parentNode
+		value, err = s.currentPath.Get(currentNode.Height)
+		if err != nil {
+			return
+		}
 	}
 
 	// Get the state hash before attempting a fork.
@@ -361,90 +421,172 @@ func (s *State) forkBlockchain(newNode *BlockNode) (rewoundBlocks []Block, appli
 		stateHash = s.StateHash()
 	}
 
-	// Remove blocks from the ConsensusState until we get to the
-	// same parent that we are forking from.
-	for s.currentBlockID != currentNode.Block.ID() {
-		rewoundBlocks = append(rewoundBlocks, s.CurrentBlock())
-		cc.InvertedBlocks = append(cc.InvertedBlocks, s.currentBlockNode().BlockDiff)
-		outputDiffs = append(outputDiffs, s.invertRecentBlock()...)
+	// Remove blocks from the ConsensusState until we get to the same parent
+	// that we are forking from. These blocks are already part of the
+	// consensus state, so each inversion here is unconditional - batches are
+	// flushed to subscribers as soon as they fill up rather than waiting for
+	// the whole rewind (which may be thousands of blocks deep) to finish.
+	var rewoundIDs []BlockID
+	var rewindBatch ConsensusChange
+	for s.currentBlockID != currentID {
+		rewoundIDs = append(rewoundIDs, s.currentBlockID)
+		rewindBatch.InvertedBlocks = append(rewindBatch.InvertedBlocks, *s.currentBlockNode().Diff)
+		if _, err = s.invertRecentBlock(); err != nil {
+			return
+		}
+		if len(rewindBatch.InvertedBlocks) >= reorgBatchSize {
+			s.notifySubscribers(rewindBatch)
+			rewindBatch = ConsensusChange{}
+		}
+	}
+	if len(rewindBatch.InvertedBlocks) > 0 {
+		s.notifySubscribers(rewindBatch)
 	}
 
-	// Validate each block in the parent history in order, updating
-	// the state as we go.  If at some point a block doesn't
-	// verify, you get to walk all the way backwards and forwards
-	// again.
+	// Validate each block in the parent history in order, updating the state
+	// as we go. If at some point a block doesn't verify, the whole fork is
+	// abandoned: everything integrated so far (including already-notified
+	// batches) is unwound, and the original chain is reintegrated with
+	// compensating notifications so subscribers end up exactly where they
+	// started.
+	var appliedIDs []BlockID
+	var applyBatch ConsensusChange
 	validatedBlocks := 0
+	forkFailed := false
 	for i := len(parentHistory) - 1; i >= 0; i-- {
-		appliedBlock := *s.blockMap[parentHistory[i]].Block
-		appliedBlocks = append(appliedBlocks, appliedBlock)
-		var bd BlockDiff
-		diffSet, err := s.integrateBlock(appliedBlock, &bd)
-		if err != nil {
-			// Add the whole tree of blocks to BadBlocks,
-			// deleting them from BlockMap
-			s.invalidateNode(s.blockMap[parentHistory[i]])
+		appliedBlock, blockErr := s.index.Block(parentHistory[i])
+		if blockErr != nil {
+			err = blockErr
+			forkFailed = true
+			break
+		}
 
-			// Rewind the validated blocks
-			for i := 0; i < validatedBlocks; i++ {
-				s.invertRecentBlock()
+		var bd BlockDiff
+		_, integrateErr := s.integrateBlock(appliedBlock, &bd)
+		if integrateErr != nil {
+			err = integrateErr
+			// Add this block to BadBlocks, deleting it from the index. Its
+			// descendants are left in place (see invalidateNode).
+			if invalidateErr := s.invalidateNode(parentHistory[i]); invalidateErr != nil {
+				err = fmt.Errorf("%v (also failed to invalidate %v: %v)", err, parentHistory[i], invalidateErr)
 			}
+			forkFailed = true
+			break
+		}
+
+		appliedIDs = append(appliedIDs, parentHistory[i])
+		applyBatch.AppliedBlocks = append(applyBatch.AppliedBlocks, bd)
+		validatedBlocks++
 
-			// Integrate the rewound blocks
-			for i := len(rewoundBlocks) - 1; i >= 0; i-- {
-				_, err = s.integrateBlock(rewoundBlocks[i], &BlockDiff{}) // this diff is not used, because the state has not changed. TODO: change how reapply works.
-				if err != nil {
-					panic("Once-validated blocks are no longer validating - state logic has mistakes.")
-				}
+		if len(applyBatch.AppliedBlocks) >= reorgBatchSize {
+			s.notifySubscribers(applyBatch)
+			applyBatch = ConsensusChange{}
+		}
+	}
+
+	if forkFailed {
+		// Flush whatever batch was being assembled when the failure hit.
+		if len(applyBatch.AppliedBlocks) > 0 {
+			s.notifySubscribers(applyBatch)
+			applyBatch = ConsensusChange{}
+		}
+
+		// Rewind every block that was integrated for the failed fork. These
+		// blocks were only just integrated a moment ago by this same
+		// function, so a failure here means state logic has a mistake, not
+		// that the ChainStore is genuinely flaky - consistent with the
+		// reintegration panic below. Subscribers are told about each
+		// inversion, since they already heard about the applied blocks.
+		var undoForkBatch ConsensusChange
+		for i := 0; i < validatedBlocks; i++ {
+			undoForkBatch.InvertedBlocks = append(undoForkBatch.InvertedBlocks, *s.currentBlockNode().Diff)
+			if _, undoErr := s.invertRecentBlock(); undoErr != nil {
+				panic("Just-integrated blocks are failing to invert - state logic has mistakes.")
+			}
+			if len(undoForkBatch.InvertedBlocks) >= reorgBatchSize {
+				s.notifySubscribers(undoForkBatch)
+				undoForkBatch = ConsensusChange{}
 			}
+		}
+		if len(undoForkBatch.InvertedBlocks) > 0 {
+			s.notifySubscribers(undoForkBatch)
+		}
 
-			// Reset diffs to nil since nothing in sum was changed.
-			appliedBlocks = nil
-			rewoundBlocks = nil
-			outputDiffs = nil
-			bd = BlockDiff{}
-
-			// Check that the state hash is the same as before forking and then returning.
-			if DEBUG {
-				if stateHash != s.StateHash() {
-					panic("state hash does not match after an unsuccessful fork attempt")
-				}
+		// Reintegrate the blocks that were rewound above, restoring the
+		// original chain, and tell subscribers what just happened.
+		var undoBatch ConsensusChange
+		for i := len(rewoundIDs) - 1; i >= 0; i-- {
+			rewoundBlock, fetchErr := s.index.Block(rewoundIDs[i])
+			if fetchErr != nil {
+				panic("a just-rewound block is missing from the index - state logic has mistakes.")
+			}
+			var bd BlockDiff
+			_, reErr := s.integrateBlock(rewoundBlock, &bd) // this diff is not used, because the state has not changed. TODO: change how reapply works.
+			if reErr != nil {
+				panic("Once-validated blocks are no longer validating - state logic has mistakes.")
 			}
+			undoBatch.AppliedBlocks = append(undoBatch.AppliedBlocks, bd)
+			if len(undoBatch.AppliedBlocks) >= reorgBatchSize {
+				s.notifySubscribers(undoBatch)
+				undoBatch = ConsensusChange{}
+			}
+		}
+		if len(undoBatch.AppliedBlocks) > 0 {
+			s.notifySubscribers(undoBatch)
+		}
 
-			break
+		// Reset counts to zero since nothing in sum was changed.
+		rewoundCount = 0
+		appliedCount = 0
+
+		// Check that the state hash is the same as before forking.
+		if DEBUG {
+			if stateHash != s.StateHash() {
+				panic("state hash does not match after an unsuccessful fork attempt")
+			}
 		}
-		cc.AppliedBlocks = append(cc.AppliedBlocks, bd)
-		s.blockMap[parentHistory[i]].BlockDiff = bd
-		// TODO: Add the block diff to the block node, for retrieval during inversion.
-		validatedBlocks += 1
-		outputDiffs = append(outputDiffs, diffSet...)
+
+		s.cleanTransactionPool()
+		return
+	}
+
+	if len(applyBatch.AppliedBlocks) > 0 {
+		s.notifySubscribers(applyBatch)
 	}
 
 	// Update the transaction pool to remove any transactions that have
 	// invalidated on account of invalidated storage proofs.
 	s.cleanTransactionPool()
 
-	// Notify all subscribers of the changes.
-	if appliedBlocks != nil {
-		s.notifySubscribers(cc)
-	}
-
+	rewoundCount = len(rewoundIDs)
+	appliedCount = len(appliedIDs)
 	return
 }
 
 // State.AcceptBlock() will add blocks to the state, forking the blockchain if
-// they are on a fork that is heavier than the current fork.
-func (s *State) AcceptBlock(b Block) (rewoundBlocks []Block, appliedBlocks []Block, outputDiffs []OutputDiff, err error) {
+// they are on a fork that is heavier than the current fork. The returned
+// BlockAcceptStatus tells the caller whether the block extended the main
+// chain directly, was stored as a not-yet-heaviest side chain, or caused a
+// reorg; subscribers that need the actual diffs get them through
+// notifySubscribers instead, the same way forkBlockchain delivers them.
+func (s *State) AcceptBlock(b Block) (status BlockAcceptStatus, err error) {
 	// TODO: Before spending a lot of computational resources on verifying a
 	// block, we need to check that the block at least represents a reasonable
 	// amount of work done, which will help mitigate certain types of DoS
 	// attacks.
 
+	status = StatusSideChain
+
 	// Check the maps in the state to see if the block is already known.
 	err = s.checkDestiny(b)
 	if err != nil {
 		return
 	}
-	parentNode := s.blockMap[b.ParentBlockID]
+	parentNode, err := s.index.Node(b.ParentBlockID)
+	if err != nil {
+		err = ErrUnknownAncestor
+		return
+	}
 
 	// Check that the header of the block is acceptible.
 	err = s.validateHeader(parentNode, &b)
@@ -455,18 +597,30 @@ func (s *State) AcceptBlock(b Block) (rewoundBlocks []Block, appliedBlocks []Blo
 	// Check that the block is the correct size.
 	encodedBlock := encoding.Marshal(b)
 	if len(encodedBlock) > BlockSizeLimit {
-		err = errors.New("Block is too large, will not be accepted.")
+		err = s.markBad(b.ID(), ErrBlockTooLarge)
 		return
 	}
 
-	newBlockNode := s.addBlockToTree(parentNode, &b)
+	// Persist the block as a known-valid header. If it is not heavy enough
+	// to overtake the current chain, it stays a side chain: its transactions
+	// are never applied unless forkBlockchain later walks over it.
+	newBlockNode, err := s.addBlockToTree(parentNode, &b)
+	if err != nil {
+		return
+	}
 
 	// If the new node is 5% heavier than the current node, switch to the new fork.
 	if s.heavierFork(newBlockNode) {
-		rewoundBlocks, appliedBlocks, outputDiffs, err = s.forkBlockchain(newBlockNode)
+		var rewoundCount int
+		rewoundCount, _, err = s.forkBlockchain(b.ID(), newBlockNode)
 		if err != nil {
 			return
 		}
+		if rewoundCount == 0 {
+			status = StatusCanonical
+		} else {
+			status = StatusReorg
+		}
 	}
 
 	// Notify subscribers of the consensus change.
@@ -478,5 +632,9 @@ func (s *State) AcceptBlock(b Block) (rewoundBlocks []Block, appliedBlocks []Blo
 		s.CurrentPathCheck()
 	}
 
+	// Now that b is known to the index, give any orphans that were waiting
+	// on it a chance to be accepted too.
+	s.promoteOrphans(b.ID())
+
 	return
 }
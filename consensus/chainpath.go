@@ -0,0 +1,112 @@
+package consensus
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxPathEntries bounds how many height -> BlockID mappings a
+// ChainPath keeps cached in memory.
+const DefaultMaxPathEntries = 100e3
+
+// pathEntry is the value stored in a ChainPath's LRU list.
+type pathEntry struct {
+	height BlockHeight
+	id     BlockID
+}
+
+// A ChainPath is a concurrency-safe, memory-bounded view onto a ChainStore's
+// record of which BlockID sits at each height along the current main chain.
+// It replaces an earlier plain `map[BlockHeight]BlockID` that, like the
+// original blockMap, covered the entire chain height with no persistence and
+// no bound on RAM.
+type ChainPath struct {
+	mu    sync.Mutex
+	cache map[BlockHeight]*list.Element
+	lru   *list.List // front = most recently touched
+	max   int
+	store ChainStore
+}
+
+// NewChainPath returns a ChainPath backed by the given ChainStore, caching
+// up to DefaultMaxPathEntries heights in memory.
+func NewChainPath(store ChainStore) *ChainPath {
+	return NewBoundedChainPath(store, DefaultMaxPathEntries)
+}
+
+// NewBoundedChainPath returns a ChainPath backed by the given ChainStore,
+// caching up to max heights in memory.
+func NewBoundedChainPath(store ChainStore, max int) *ChainPath {
+	return &ChainPath{
+		cache: make(map[BlockHeight]*list.Element),
+		lru:   list.New(),
+		max:   max,
+		store: store,
+	}
+}
+
+// Set records id as the main-chain block at height.
+func (cp *ChainPath) Set(height BlockHeight, id BlockID) error {
+	if err := cp.store.SaveHeight(height, id); err != nil {
+		return err
+	}
+	cp.mu.Lock()
+	cp.cacheLocked(height, id)
+	cp.mu.Unlock()
+	return nil
+}
+
+// Get returns the BlockID of the main-chain block at height, checking the
+// in-memory cache before falling back to the ChainStore.
+func (cp *ChainPath) Get(height BlockHeight) (BlockID, error) {
+	cp.mu.Lock()
+	if elem, cached := cp.cache[height]; cached {
+		cp.lru.MoveToFront(elem)
+		id := elem.Value.(*pathEntry).id
+		cp.mu.Unlock()
+		return id, nil
+	}
+	cp.mu.Unlock()
+
+	id, err := cp.store.LoadHeight(height)
+	if err != nil {
+		return BlockID{}, err
+	}
+	cp.mu.Lock()
+	cp.cacheLocked(height, id)
+	cp.mu.Unlock()
+	return id, nil
+}
+
+// Remove forgets the BlockID at height, e.g. because that block is being
+// rewound during a reorg.
+func (cp *ChainPath) Remove(height BlockHeight) error {
+	cp.mu.Lock()
+	if elem, exists := cp.cache[height]; exists {
+		cp.lru.Remove(elem)
+		delete(cp.cache, height)
+	}
+	cp.mu.Unlock()
+	return cp.store.RemoveHeight(height)
+}
+
+// cacheLocked inserts or refreshes height's entry at the front of the LRU,
+// evicting the least-recently-touched entry if this pushes the cache over
+// max. Callers must hold cp.mu.
+func (cp *ChainPath) cacheLocked(height BlockHeight, id BlockID) {
+	if elem, exists := cp.cache[height]; exists {
+		elem.Value.(*pathEntry).id = id
+		cp.lru.MoveToFront(elem)
+		return
+	}
+	elem := cp.lru.PushFront(&pathEntry{height: height, id: id})
+	cp.cache[height] = elem
+	for cp.lru.Len() > cp.max {
+		back := cp.lru.Back()
+		if back == nil {
+			break
+		}
+		cp.lru.Remove(back)
+		delete(cp.cache, back.Value.(*pathEntry).height)
+	}
+}
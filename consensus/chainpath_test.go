@@ -0,0 +1,54 @@
+package consensus
+
+import "testing"
+
+// TestChainPathCacheBound verifies that Set evicts the least-recently-touched
+// height once the cache reaches its max size, while the evicted height
+// remains retrievable by falling through to the ChainStore.
+func TestChainPathCacheBound(t *testing.T) {
+	store := newFakeChainStore()
+	cp := NewBoundedChainPath(store, 2)
+
+	if err := cp.Set(0, BlockID{1}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cp.Set(1, BlockID{2}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cp.Set(2, BlockID{3}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if len(cp.cache) != 2 {
+		t.Fatalf("expected cache bounded at 2 entries, got %d", len(cp.cache))
+	}
+	if _, cached := cp.cache[0]; cached {
+		t.Fatalf("expected least-recently-touched height to have been evicted from the cache")
+	}
+
+	id, err := cp.Get(0)
+	if err != nil {
+		t.Fatalf("expected evicted height to still be loadable from the store, got %v", err)
+	}
+	if id != (BlockID{1}) {
+		t.Fatalf("expected loaded BlockID to match what was saved, got %v", id)
+	}
+}
+
+// TestChainPathRemove verifies that Remove clears both the cache entry and
+// the underlying ChainStore record.
+func TestChainPathRemove(t *testing.T) {
+	store := newFakeChainStore()
+	cp := NewChainPath(store)
+	if err := cp.Set(5, BlockID{9}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := cp.Remove(5); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if _, err := cp.Get(5); err == nil {
+		t.Fatalf("expected Get to fail for a removed height")
+	}
+}
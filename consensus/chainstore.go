@@ -0,0 +1,53 @@
+package consensus
+
+// A ChainStore persists everything a State needs to survive a restart: full
+// blocks (so transactions can be reapplied or served to peers), the slim
+// BlockNode for each known block, the current tip of the main chain, the
+// BlockID at each height along the main chain (the "current path"), and
+// every block that has been found permanently invalid. Implementations are
+// expected to be safe for concurrent use, since BlockIndex, ChainPath, and
+// BadBlockSet may all call through from multiple goroutines.
+type ChainStore interface {
+	// SaveBlock persists the full body of a block.
+	SaveBlock(Block) error
+
+	// LoadBlock retrieves the full body of a previously saved block.
+	LoadBlock(BlockID) (Block, error)
+
+	// SaveNode persists the BlockNode for the given block. The BlockID is
+	// passed explicitly because BlockNode no longer carries a copy of the
+	// block it was built from.
+	SaveNode(BlockID, *BlockNode) error
+
+	// LoadNode retrieves a previously saved BlockNode.
+	LoadNode(BlockID) (*BlockNode, error)
+
+	// RemoveNode removes a BlockNode, e.g. because the block it corresponds
+	// to has been invalidated.
+	RemoveNode(BlockID) error
+
+	// SaveTip records the BlockID of the current main-chain tip.
+	SaveTip(BlockID) error
+
+	// LoadTip returns the BlockID most recently passed to SaveTip.
+	LoadTip() (BlockID, error)
+
+	// SaveHeight records the BlockID of the main-chain block at height.
+	SaveHeight(BlockHeight, BlockID) error
+
+	// LoadHeight retrieves the BlockID previously saved for height.
+	LoadHeight(BlockHeight) (BlockID, error)
+
+	// RemoveHeight forgets the BlockID previously saved for height, e.g.
+	// because the block at that height is being rewound during a reorg.
+	RemoveHeight(BlockHeight) error
+
+	// SaveBadBlock records id as permanently invalid.
+	SaveBadBlock(BlockID) error
+
+	// IsBadBlock reports whether id was previously passed to SaveBadBlock.
+	IsBadBlock(BlockID) (bool, error)
+
+	// Close releases any resources held by the store.
+	Close() error
+}
@@ -0,0 +1,135 @@
+package consensus
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/encoding"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// LevelDBStore is the on-disk ChainStore implementation. Blocks and nodes
+// are stored under separate key prefixes in a single LevelDB database so
+// that a node restart only has to open one file and replay nothing - the
+// BlockIndex repopulates its in-memory cache lazily, on demand, from here.
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+const (
+	blockPrefix    = "b-"
+	nodePrefix     = "n-"
+	tipKey         = "tip"
+	heightPrefix   = "h-"
+	badBlockPrefix = "x-"
+)
+
+// NewLevelDBStore opens (and if necessary creates) a LevelDB-backed
+// ChainStore at the given path.
+func NewLevelDBStore(path string) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+// SaveBlock implements the ChainStore interface.
+func (s *LevelDBStore) SaveBlock(b Block) error {
+	return s.db.Put([]byte(blockPrefix+string(b.ID()[:])), encoding.Marshal(b), nil)
+}
+
+// LoadBlock implements the ChainStore interface.
+func (s *LevelDBStore) LoadBlock(id BlockID) (b Block, err error) {
+	data, err := s.db.Get([]byte(blockPrefix+string(id[:])), nil)
+	if err != nil {
+		return
+	}
+	err = encoding.Unmarshal(data, &b)
+	return
+}
+
+// SaveNode implements the ChainStore interface.
+func (s *LevelDBStore) SaveNode(id BlockID, bn *BlockNode) error {
+	return s.db.Put([]byte(nodePrefix+string(id[:])), encoding.Marshal(*bn), nil)
+}
+
+// LoadNode implements the ChainStore interface.
+func (s *LevelDBStore) LoadNode(id BlockID) (*BlockNode, error) {
+	data, err := s.db.Get([]byte(nodePrefix+string(id[:])), nil)
+	if err != nil {
+		return nil, err
+	}
+	var bn BlockNode
+	if err = encoding.Unmarshal(data, &bn); err != nil {
+		return nil, err
+	}
+	return &bn, nil
+}
+
+// RemoveNode implements the ChainStore interface.
+func (s *LevelDBStore) RemoveNode(id BlockID) error {
+	return s.db.Delete([]byte(nodePrefix+string(id[:])), nil)
+}
+
+// SaveTip implements the ChainStore interface.
+func (s *LevelDBStore) SaveTip(id BlockID) error {
+	return s.db.Put([]byte(tipKey), id[:], nil)
+}
+
+// LoadTip implements the ChainStore interface.
+func (s *LevelDBStore) LoadTip() (id BlockID, err error) {
+	data, err := s.db.Get([]byte(tipKey), nil)
+	if err != nil {
+		return
+	}
+	if len(data) != len(id) {
+		err = errors.New("corrupt tip entry in chain store")
+		return
+	}
+	copy(id[:], data)
+	return
+}
+
+// SaveHeight implements the ChainStore interface.
+func (s *LevelDBStore) SaveHeight(height BlockHeight, id BlockID) error {
+	return s.db.Put([]byte(heightPrefix+string(encoding.Marshal(height))), id[:], nil)
+}
+
+// LoadHeight implements the ChainStore interface.
+func (s *LevelDBStore) LoadHeight(height BlockHeight) (id BlockID, err error) {
+	data, err := s.db.Get([]byte(heightPrefix+string(encoding.Marshal(height))), nil)
+	if err != nil {
+		return
+	}
+	if len(data) != len(id) {
+		err = errors.New("corrupt height entry in chain store")
+		return
+	}
+	copy(id[:], data)
+	return
+}
+
+// RemoveHeight implements the ChainStore interface.
+func (s *LevelDBStore) RemoveHeight(height BlockHeight) error {
+	return s.db.Delete([]byte(heightPrefix+string(encoding.Marshal(height))), nil)
+}
+
+// SaveBadBlock implements the ChainStore interface.
+func (s *LevelDBStore) SaveBadBlock(id BlockID) error {
+	return s.db.Put([]byte(badBlockPrefix+string(id[:])), []byte{1}, nil)
+}
+
+// IsBadBlock implements the ChainStore interface.
+func (s *LevelDBStore) IsBadBlock(id BlockID) (bool, error) {
+	exists, err := s.db.Has([]byte(badBlockPrefix+string(id[:])), nil)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// Close implements the ChainStore interface.
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,91 @@
+package consensus
+
+import "testing"
+
+// TestLevelDBStoreRoundTrip verifies that everything LevelDBStore saves comes
+// back unchanged, including the "not found" case RemoveNode/RemoveHeight are
+// expected to leave behind.
+func TestLevelDBStoreRoundTrip(t *testing.T) {
+	store, err := NewLevelDBStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLevelDBStore failed: %v", err)
+	}
+	defer store.Close()
+
+	b := Block{Timestamp: 123}
+	if err := store.SaveBlock(b); err != nil {
+		t.Fatalf("SaveBlock failed: %v", err)
+	}
+	gotBlock, err := store.LoadBlock(b.ID())
+	if err != nil {
+		t.Fatalf("LoadBlock failed: %v", err)
+	}
+	if gotBlock.Timestamp != b.Timestamp {
+		t.Fatalf("expected loaded block to match saved block, got timestamp %d", gotBlock.Timestamp)
+	}
+
+	id := BlockID{1}
+	node := &BlockNode{Height: 4, Timestamp: 99}
+	if err := store.SaveNode(id, node); err != nil {
+		t.Fatalf("SaveNode failed: %v", err)
+	}
+	gotNode, err := store.LoadNode(id)
+	if err != nil {
+		t.Fatalf("LoadNode failed: %v", err)
+	}
+	if gotNode.Height != node.Height || gotNode.Timestamp != node.Timestamp {
+		t.Fatalf("expected loaded node to match saved node, got %+v", gotNode)
+	}
+	if err := store.RemoveNode(id); err != nil {
+		t.Fatalf("RemoveNode failed: %v", err)
+	}
+	if _, err := store.LoadNode(id); err == nil {
+		t.Fatalf("expected LoadNode to fail for a removed node")
+	}
+
+	if err := store.SaveTip(id); err != nil {
+		t.Fatalf("SaveTip failed: %v", err)
+	}
+	gotTip, err := store.LoadTip()
+	if err != nil {
+		t.Fatalf("LoadTip failed: %v", err)
+	}
+	if gotTip != id {
+		t.Fatalf("expected loaded tip to match saved tip, got %v", gotTip)
+	}
+
+	if err := store.SaveHeight(10, id); err != nil {
+		t.Fatalf("SaveHeight failed: %v", err)
+	}
+	gotHeight, err := store.LoadHeight(10)
+	if err != nil {
+		t.Fatalf("LoadHeight failed: %v", err)
+	}
+	if gotHeight != id {
+		t.Fatalf("expected loaded height entry to match saved one, got %v", gotHeight)
+	}
+	if err := store.RemoveHeight(10); err != nil {
+		t.Fatalf("RemoveHeight failed: %v", err)
+	}
+	if _, err := store.LoadHeight(10); err == nil {
+		t.Fatalf("expected LoadHeight to fail for a removed height")
+	}
+
+	if err := store.SaveBadBlock(id); err != nil {
+		t.Fatalf("SaveBadBlock failed: %v", err)
+	}
+	known, err := store.IsBadBlock(id)
+	if err != nil {
+		t.Fatalf("IsBadBlock failed: %v", err)
+	}
+	if !known {
+		t.Fatalf("expected saved bad block to be reported known")
+	}
+	known, err = store.IsBadBlock(BlockID{2})
+	if err != nil {
+		t.Fatalf("IsBadBlock failed: %v", err)
+	}
+	if known {
+		t.Fatalf("expected an unsaved BlockID to be reported unknown")
+	}
+}
@@ -0,0 +1,103 @@
+package consensus
+
+import "errors"
+
+// errNotFoundInStore is returned by fakeChainStore's Load* methods, mirroring
+// the "missing key" error LevelDBStore's Get calls would return.
+var errNotFoundInStore = errors.New("not found in fake chain store")
+
+// fakeChainStore is a minimal, non-persistent ChainStore backed by plain
+// maps, letting BlockIndex, ChainPath, and BadBlockSet be tested without
+// standing up a real LevelDBStore.
+type fakeChainStore struct {
+	blocks    map[BlockID]Block
+	nodes     map[BlockID]*BlockNode
+	tip       BlockID
+	hasTip    bool
+	heights   map[BlockHeight]BlockID
+	badBlocks map[BlockID]bool
+}
+
+func newFakeChainStore() *fakeChainStore {
+	return &fakeChainStore{
+		blocks:    make(map[BlockID]Block),
+		nodes:     make(map[BlockID]*BlockNode),
+		heights:   make(map[BlockHeight]BlockID),
+		badBlocks: make(map[BlockID]bool),
+	}
+}
+
+func (f *fakeChainStore) SaveBlock(b Block) error {
+	f.blocks[b.ID()] = b
+	return nil
+}
+
+func (f *fakeChainStore) LoadBlock(id BlockID) (Block, error) {
+	b, exists := f.blocks[id]
+	if !exists {
+		return Block{}, errNotFoundInStore
+	}
+	return b, nil
+}
+
+func (f *fakeChainStore) SaveNode(id BlockID, bn *BlockNode) error {
+	f.nodes[id] = bn
+	return nil
+}
+
+func (f *fakeChainStore) LoadNode(id BlockID) (*BlockNode, error) {
+	bn, exists := f.nodes[id]
+	if !exists {
+		return nil, errNotFoundInStore
+	}
+	return bn, nil
+}
+
+func (f *fakeChainStore) RemoveNode(id BlockID) error {
+	delete(f.nodes, id)
+	return nil
+}
+
+func (f *fakeChainStore) SaveTip(id BlockID) error {
+	f.tip = id
+	f.hasTip = true
+	return nil
+}
+
+func (f *fakeChainStore) LoadTip() (BlockID, error) {
+	if !f.hasTip {
+		return BlockID{}, errNotFoundInStore
+	}
+	return f.tip, nil
+}
+
+func (f *fakeChainStore) SaveHeight(height BlockHeight, id BlockID) error {
+	f.heights[height] = id
+	return nil
+}
+
+func (f *fakeChainStore) LoadHeight(height BlockHeight) (BlockID, error) {
+	id, exists := f.heights[height]
+	if !exists {
+		return BlockID{}, errNotFoundInStore
+	}
+	return id, nil
+}
+
+func (f *fakeChainStore) RemoveHeight(height BlockHeight) error {
+	delete(f.heights, height)
+	return nil
+}
+
+func (f *fakeChainStore) SaveBadBlock(id BlockID) error {
+	f.badBlocks[id] = true
+	return nil
+}
+
+func (f *fakeChainStore) IsBadBlock(id BlockID) (bool, error) {
+	return f.badBlocks[id], nil
+}
+
+func (f *fakeChainStore) Close() error {
+	return nil
+}
@@ -0,0 +1,89 @@
+package consensus
+
+import "errors"
+
+// Sentinel errors returned by block validation. Previously these were all
+// constructed ad-hoc with errors.New, which meant callers could only
+// distinguish failure modes by comparing strings. These let a caller like
+// the net layer tell "this block will never be valid, ban whoever sent it"
+// apart from "this isn't valid yet, try again later" apart from "we don't
+// have this block's parent, go ask for it."
+var (
+	// ErrBlockKnown is returned when a block has already been accepted into
+	// the index. A block known to be invalid gets ErrKnownBadBlock instead.
+	ErrBlockKnown = errors.New("block exists in block map.")
+
+	// ErrKnownBadBlock is returned when a block was previously found to be
+	// invalid and is being seen again.
+	ErrKnownBadBlock = errors.New("block is known to be invalid")
+
+	// ErrKnownOrphan is returned when a block is already waiting in the
+	// OrphanManager for its parent to arrive.
+	ErrKnownOrphan = errors.New("block is a known orphan")
+
+	// ErrUnknownOrphan is returned the first time a block with an unknown
+	// parent is seen; it has been handed to the OrphanManager.
+	ErrUnknownOrphan = errors.New("block is an unknown orphan")
+
+	// ErrFutureBlock is returned when a block's timestamp is ahead of the
+	// local clock by more than FutureThreshold. The block may still be
+	// valid. If it is no more than MaxTimeFutureBlocks ahead it has been
+	// queued and will be retried once its timestamp falls within
+	// FutureThreshold of the present; a block further ahead than that is
+	// not queued.
+	ErrFutureBlock = errors.New("timestamp too far in future, will try again later.")
+
+	// ErrUnknownAncestor is returned when a block's parent cannot be found
+	// in the index at a point where it is expected to exist.
+	ErrUnknownAncestor = errors.New("block's parent is not known")
+
+	// ErrInvalidPoW is returned when a block's ID does not meet its parent's
+	// target.
+	ErrInvalidPoW = errors.New("block does not meet target")
+
+	// ErrOldTimestamp is returned when a block's timestamp is earlier than
+	// the earliest timestamp its parent allows.
+	ErrOldTimestamp = errors.New("timestamp invalid for being in the past")
+
+	// ErrMerkleMismatch is returned when a block's transaction merkle root
+	// does not match the transactions it carries.
+	ErrMerkleMismatch = errors.New("merkle root does not match transactions sent.")
+
+	// ErrBlockTooLarge is returned when a block's encoded size exceeds
+	// BlockSizeLimit.
+	ErrBlockTooLarge = errors.New("block is too large, will not be accepted")
+)
+
+// banReasons holds every error that indicates a block - and, by extension,
+// the peer that served it - is permanently invalid, as opposed to merely
+// rejected for now.
+var banReasons = map[error]bool{
+	ErrKnownBadBlock:  true,
+	ErrInvalidPoW:     true,
+	ErrOldTimestamp:   true,
+	ErrMerkleMismatch: true,
+	ErrBlockTooLarge:  true,
+}
+
+// IsBanReason reports whether err indicates a block is permanently invalid.
+// The net layer can use this to decide whether to drop the peer that served
+// the block, as opposed to retrying later (ErrFutureBlock), asking for the
+// missing parent (ErrUnknownAncestor), ignoring it as a duplicate
+// (ErrBlockKnown, ErrKnownOrphan), or leaving it with the OrphanManager
+// (ErrUnknownOrphan).
+func IsBanReason(err error) bool {
+	return banReasons[err]
+}
+
+// Deprecated: BlockKnownErr, KnownOrphanErr, UnknownOrphanErr, and
+// FutureBlockErr were already exported sentinels before this file existed.
+// They are kept as aliases of their Err-prefixed replacements so that
+// callers outside this package which still reference the old names keep
+// compiling; new code should use ErrBlockKnown, ErrKnownOrphan,
+// ErrUnknownOrphan, and ErrFutureBlock directly.
+var (
+	BlockKnownErr    = ErrBlockKnown
+	KnownOrphanErr   = ErrKnownOrphan
+	UnknownOrphanErr = ErrUnknownOrphan
+	FutureBlockErr   = ErrFutureBlock
+)
@@ -0,0 +1,158 @@
+package consensus
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// MaxFutureBlocks bounds how many blocks with a future timestamp the State
+// will hold onto at once, waiting for their timestamp to become valid.
+// Without this bound, a peer could send a flood of blocks timestamped hours
+// ahead and spawn an unbounded number of goroutines each pinned to a full
+// Block.
+const MaxFutureBlocks = 256
+
+// MaxTimeFutureBlocks is how far into the future a block's timestamp is
+// allowed to be before it is rejected outright instead of being queued to
+// retry later.
+var MaxTimeFutureBlocks = Timestamp(3 * 60 * 60)
+
+// futureBlockQueue is a size-bounded, concurrency-safe holding area for
+// blocks whose timestamp is ahead of the local clock by more than
+// FutureThreshold. It evicts the oldest entry once MaxFutureBlocks is
+// exceeded.
+type futureBlockQueue struct {
+	mu      sync.Mutex
+	entries map[BlockID]*list.Element
+	order   *list.List // front = most recently queued
+	max     int
+}
+
+func newFutureBlockQueue(max int) *futureBlockQueue {
+	return &futureBlockQueue{
+		entries: make(map[BlockID]*list.Element),
+		order:   list.New(),
+		max:     max,
+	}
+}
+
+// Add queues b, evicting the oldest queued block if the queue is already at
+// capacity. Adding a block that is already queued is a no-op.
+func (q *futureBlockQueue) Add(b Block) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	id := b.ID()
+	if _, exists := q.entries[id]; exists {
+		return
+	}
+	for len(q.entries) >= q.max {
+		back := q.order.Back()
+		if back == nil {
+			break
+		}
+		delete(q.entries, back.Value.(Block).ID())
+		q.order.Remove(back)
+	}
+	q.entries[id] = q.order.PushFront(b)
+}
+
+// Remove evicts a block from the queue, e.g. because the reaper has already
+// given it a chance to be accepted.
+func (q *futureBlockQueue) Remove(id BlockID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	elem, exists := q.entries[id]
+	if !exists {
+		return
+	}
+	q.order.Remove(elem)
+	delete(q.entries, id)
+}
+
+// Blocks returns a snapshot of every block currently queued.
+func (q *futureBlockQueue) Blocks() []Block {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	blocks := make([]Block, 0, len(q.entries))
+	for elem := q.order.Front(); elem != nil; elem = elem.Next() {
+		blocks = append(blocks, elem.Value.(Block))
+	}
+	return blocks
+}
+
+// A futureBlockReaper periodically walks the future block queue and
+// resubmits any block whose timestamp has come within FutureThreshold of the
+// local clock, so that a block which was merely queued for being slightly
+// ahead of time is not lost.
+type futureBlockReaper struct {
+	state  *State
+	queue  *futureBlockQueue
+	config ChainConfig
+	ticker *time.Ticker
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// newFutureBlockReaper starts the background goroutine that drives reaping.
+// It is started once, from NewState, and lives for as long as the State
+// does; tests should call Stop() to shut it down cleanly. config is the same
+// ChainConfig the State was constructed with, so a testnet or regression-test
+// State (shorter BlockFrequency, different FutureThreshold) gets a reaper
+// that agrees with its own validator about both the tick period and when a
+// queued block is no longer too far in the future.
+func newFutureBlockReaper(s *State, queue *futureBlockQueue, config ChainConfig) *futureBlockReaper {
+	r := &futureBlockReaper{
+		state:  s,
+		queue:  queue,
+		config: config,
+		ticker: time.NewTicker(time.Duration(config.BlockFrequency/2) * time.Second),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *futureBlockReaper) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.ticker.C:
+			r.reapOnce()
+		case <-r.stop:
+			r.ticker.Stop()
+			return
+		}
+	}
+}
+
+// reapOnce resubmits every queued block whose timestamp is now within
+// FutureThreshold of the local clock. A block is evicted from the queue
+// whether AcceptBlock succeeds or fails outright; if the failure was merely
+// an unknown parent, the OrphanManager is already holding the block and will
+// retry it once the parent arrives, so there is nothing more for the future
+// block queue to do with it.
+func (r *futureBlockReaper) reapOnce() {
+	now := Timestamp(time.Now().Unix())
+	for _, b := range r.queue.Blocks() {
+		if b.Timestamp-now > r.config.FutureThreshold {
+			continue
+		}
+		r.state.AcceptBlock(b)
+		r.queue.Remove(b.ID())
+	}
+}
+
+// Stop shuts down the reaper goroutine and waits for it to exit.
+func (r *futureBlockReaper) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Stop shuts down the State's background future-block reaper. It is exposed
+// so that tests can cleanly tear down a State without leaking goroutines.
+func (s *State) Stop() {
+	s.futureReaper.Stop()
+}
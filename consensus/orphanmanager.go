@@ -0,0 +1,216 @@
+package consensus
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxOrphans is the default ceiling on the total number of orphan
+// blocks an OrphanManager will hold at once.
+const DefaultMaxOrphans = 10e3
+
+// DefaultMaxOrphansPerParent is the default ceiling on the number of orphan
+// blocks an OrphanManager will hold waiting on any single missing parent.
+const DefaultMaxOrphansPerParent = 100
+
+// DefaultOrphanTTL is how long an orphan is kept around before it is
+// considered stale and evicted, on the assumption that its parent is never
+// coming.
+const DefaultOrphanTTL = 1 * time.Hour
+
+// orphanEntry is the bookkeeping the OrphanManager keeps for each held
+// orphan, beyond the Block itself.
+type orphanEntry struct {
+	block    Block
+	parentID BlockID
+	addedAt  time.Time
+	elem     *list.Element
+}
+
+// An OrphanManager holds blocks whose parent has not been seen yet, bounded
+// both in total size and per-parent, with TTL-based eviction. It replaces an
+// earlier, unbounded missingParents map that let a peer OOM the node by
+// streaming an endless stream of headers with no known ancestor.
+type OrphanManager struct {
+	mu sync.Mutex
+
+	orphans  map[BlockID]*orphanEntry
+	byParent map[BlockID]map[BlockID]struct{}
+	lru      *list.List // front = most recently touched orphan ID
+
+	maxOrphans   int
+	maxPerParent int
+	ttl          time.Duration
+
+	held     int64
+	evicted  int64
+	promoted int64
+}
+
+// NewOrphanManager returns an OrphanManager bounded by maxOrphans total
+// entries, maxPerParent entries waiting on any single parent, and ttl before
+// an entry is considered stale.
+func NewOrphanManager(maxOrphans, maxPerParent int, ttl time.Duration) *OrphanManager {
+	return &OrphanManager{
+		orphans:      make(map[BlockID]*orphanEntry),
+		byParent:     make(map[BlockID]map[BlockID]struct{}),
+		lru:          list.New(),
+		maxOrphans:   maxOrphans,
+		maxPerParent: maxPerParent,
+		ttl:          ttl,
+	}
+}
+
+// AddOrphan adds b to the manager, keyed by its missing parent. It returns
+// ErrKnownOrphan if the orphan was already held, and ErrUnknownOrphan
+// otherwise - matching the calling convention of the handleOrphanBlock it
+// replaces, where every call indicates an error.
+func (om *OrphanManager) AddOrphan(b Block) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	om.reapExpiredLocked()
+
+	id := b.ID()
+	if entry, exists := om.orphans[id]; exists {
+		om.lru.MoveToFront(entry.elem)
+		return ErrKnownOrphan
+	}
+
+	parentSet, exists := om.byParent[b.ParentBlockID]
+	if !exists {
+		parentSet = make(map[BlockID]struct{})
+		om.byParent[b.ParentBlockID] = parentSet
+	}
+	for len(parentSet) >= om.maxPerParent {
+		om.evictOldestOfParentLocked(b.ParentBlockID)
+	}
+	for len(om.orphans) >= om.maxOrphans {
+		om.evictLRULocked()
+	}
+	// Eviction may have emptied parentSet and dropped it from byParent.
+	om.byParent[b.ParentBlockID] = parentSet
+
+	entry := &orphanEntry{
+		block:    b,
+		parentID: b.ParentBlockID,
+		addedAt:  time.Now(),
+	}
+	entry.elem = om.lru.PushFront(id)
+	om.orphans[id] = entry
+	parentSet[id] = struct{}{}
+	atomic.AddInt64(&om.held, 1)
+
+	return ErrUnknownOrphan
+}
+
+// GetOrphansOfParent returns every orphan block currently waiting on parent.
+func (om *OrphanManager) GetOrphansOfParent(parent BlockID) []Block {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	om.reapExpiredLocked()
+
+	var blocks []Block
+	for id := range om.byParent[parent] {
+		blocks = append(blocks, om.orphans[id].block)
+	}
+	return blocks
+}
+
+// RemoveOrphan removes a single orphan, e.g. because its parent has arrived
+// and it is about to be reprocessed.
+func (om *OrphanManager) RemoveOrphan(id BlockID) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.removeLocked(id, false)
+}
+
+// Held returns the number of orphans currently held.
+func (om *OrphanManager) Held() int64 {
+	return atomic.LoadInt64(&om.held)
+}
+
+// Evicted returns the cumulative number of orphans evicted for being stale
+// or for exceeding a capacity bound.
+func (om *OrphanManager) Evicted() int64 {
+	return atomic.LoadInt64(&om.evicted)
+}
+
+// Promoted returns the cumulative number of orphans whose parent arrived and
+// which were handed back to AcceptBlock.
+func (om *OrphanManager) Promoted() int64 {
+	return atomic.LoadInt64(&om.promoted)
+}
+
+// markPromoted records that an orphan was removed because it is being
+// reprocessed, rather than evicted.
+func (om *OrphanManager) markPromoted(id BlockID) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.removeLocked(id, true)
+}
+
+// removeLocked deletes the orphan from every index. promoted distinguishes
+// the Promoted counter from the Evicted counter; callers must hold om.mu.
+func (om *OrphanManager) removeLocked(id BlockID, promoted bool) {
+	entry, exists := om.orphans[id]
+	if !exists {
+		return
+	}
+	om.lru.Remove(entry.elem)
+	delete(om.orphans, id)
+	parentSet := om.byParent[entry.parentID]
+	delete(parentSet, id)
+	if len(parentSet) == 0 {
+		delete(om.byParent, entry.parentID)
+	}
+	atomic.AddInt64(&om.held, -1)
+	if promoted {
+		atomic.AddInt64(&om.promoted, 1)
+	} else {
+		atomic.AddInt64(&om.evicted, 1)
+	}
+}
+
+// evictLRULocked evicts the least-recently-touched orphan. Callers must hold
+// om.mu.
+func (om *OrphanManager) evictLRULocked() {
+	back := om.lru.Back()
+	if back == nil {
+		return
+	}
+	om.removeLocked(back.Value.(BlockID), false)
+}
+
+// evictOldestOfParentLocked evicts the oldest orphan waiting on parent, to
+// enforce the per-parent cap. Callers must hold om.mu.
+func (om *OrphanManager) evictOldestOfParentLocked(parent BlockID) {
+	var oldest *orphanEntry
+	for id := range om.byParent[parent] {
+		entry := om.orphans[id]
+		if oldest == nil || entry.addedAt.Before(oldest.addedAt) {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		om.removeLocked(oldest.block.ID(), false)
+	}
+}
+
+// reapExpiredLocked evicts every orphan whose TTL has elapsed. Callers must
+// hold om.mu.
+func (om *OrphanManager) reapExpiredLocked() {
+	cutoff := time.Now().Add(-om.ttl)
+	for elem := om.lru.Back(); elem != nil; {
+		id := elem.Value.(BlockID)
+		entry := om.orphans[id]
+		prev := elem.Prev()
+		if entry.addedAt.Before(cutoff) {
+			om.removeLocked(id, false)
+		}
+		elem = prev
+	}
+}
@@ -0,0 +1,83 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+// TestOrphanManagerPerParentCap verifies that AddOrphan evicts the oldest
+// orphan waiting on a given parent once maxPerParent is exceeded, rather
+// than letting a single parent's queue grow without bound.
+func TestOrphanManagerPerParentCap(t *testing.T) {
+	om := NewOrphanManager(100, 2, time.Hour)
+	parent := BlockID{1}
+	for i := 0; i < 3; i++ {
+		om.AddOrphan(Block{ParentBlockID: parent, Timestamp: Timestamp(i)})
+	}
+
+	if got := len(om.GetOrphansOfParent(parent)); got != 2 {
+		t.Fatalf("expected per-parent cap of 2, got %d orphans", got)
+	}
+	if om.Evicted() != 1 {
+		t.Fatalf("expected 1 eviction from the per-parent cap, got %d", om.Evicted())
+	}
+}
+
+// TestOrphanManagerTotalCap verifies that AddOrphan evicts the
+// least-recently-touched orphan once maxOrphans is exceeded, even across
+// different parents.
+func TestOrphanManagerTotalCap(t *testing.T) {
+	om := NewOrphanManager(2, 100, time.Hour)
+	for i := 0; i < 3; i++ {
+		parent := BlockID{byte(i)}
+		om.AddOrphan(Block{ParentBlockID: parent, Timestamp: Timestamp(i)})
+	}
+
+	if om.Held() != 2 {
+		t.Fatalf("expected total cap of 2 held orphans, got %d", om.Held())
+	}
+	if om.Evicted() != 1 {
+		t.Fatalf("expected 1 eviction from the total cap, got %d", om.Evicted())
+	}
+}
+
+// TestOrphanManagerTTLExpiry verifies that an orphan older than ttl is
+// evicted on the next call that triggers reapExpiredLocked, on the
+// assumption that its parent is never coming.
+func TestOrphanManagerTTLExpiry(t *testing.T) {
+	om := NewOrphanManager(100, 100, time.Millisecond)
+	parent := BlockID{9}
+	om.AddOrphan(Block{ParentBlockID: parent, Timestamp: 1})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if got := len(om.GetOrphansOfParent(parent)); got != 0 {
+		t.Fatalf("expected orphan to have expired, still held %d", got)
+	}
+	if om.Evicted() != 1 {
+		t.Fatalf("expected TTL expiry to count as an eviction, got %d", om.Evicted())
+	}
+}
+
+// TestOrphanManagerPromotion verifies that markPromoted removes the orphan
+// from every index and counts it separately from an ordinary eviction, so
+// operators can distinguish orphan-flood churn from orphans that actually
+// found their parent.
+func TestOrphanManagerPromotion(t *testing.T) {
+	om := NewOrphanManager(100, 100, time.Hour)
+	parent := BlockID{5}
+	b := Block{ParentBlockID: parent, Timestamp: 1}
+	om.AddOrphan(b)
+
+	om.markPromoted(b.ID())
+
+	if om.Promoted() != 1 {
+		t.Fatalf("expected 1 promotion, got %d", om.Promoted())
+	}
+	if om.Evicted() != 0 {
+		t.Fatalf("promotion should not also be counted as an eviction, got %d", om.Evicted())
+	}
+	if got := len(om.GetOrphansOfParent(parent)); got != 0 {
+		t.Fatalf("expected orphan to be removed after promotion, still held %d", got)
+	}
+}
@@ -0,0 +1,136 @@
+package consensus
+
+import (
+	"math/big"
+	"sort"
+)
+
+// A BlockValidator checks whether blocks and headers satisfy the consensus
+// rules, independently of any particular State. Pulling the rules out of
+// State like this makes it possible to unit test them in isolation, and to
+// swap in an alternative rule set - a testnet with a shorter TargetWindow, or
+// a regression-test validator with PoW disabled - without touching
+// AcceptBlock.
+type BlockValidator interface {
+	// ValidateHeader checks the header rules that depend on chain history
+	// but not on proof of work, e.g. that a block's timestamp is not
+	// earlier than its parent's earliest allowed child timestamp.
+	ValidateHeader(parent *BlockNode, b *Block) error
+
+	// ValidateBody checks the rules that depend only on the block itself,
+	// e.g. that the transaction merkle root matches.
+	ValidateBody(b *Block) error
+
+	// VerifyPoW checks that b's ID meets target.
+	VerifyPoW(b *Block, target Target) error
+}
+
+// A ChainConfig holds every tunable consensus parameter. Bundling these
+// together, rather than leaving them as independent package-level vars,
+// lets a State be constructed with a different rule set entirely instead of
+// every testnet or regression test having to mutate shared globals.
+type ChainConfig struct {
+	BlockFrequency        Timestamp
+	TargetWindow          BlockHeight
+	MedianTimestampWindow int
+	FutureThreshold       Timestamp
+	MaxAdjustmentUp       *big.Rat
+	MaxAdjustmentDown     *big.Rat
+
+	// SurpassThreshold dictates how much heavier a competing chain has to be
+	// before the node will switch to mining on that chain. It is set to 5%
+	// by default, which actually means that the heavier chain needs to be
+	// heavier by 5% of _one block_, not 5% heavier as a whole. This rule is
+	// in place because the difficulty gets updated every block, and that
+	// means that of two competing blocks, one could be very slightly
+	// heavier. The slightly heavier one should not be switched to if it was
+	// not seen first, because the amount of extra weight in the chain is
+	// inconsequential. The maximum difficulty shift will prevent people from
+	// manipulating timestamps enough to produce a block that is
+	// substantially heavier, thus making 5% an acceptible value.
+	SurpassThreshold *big.Rat
+}
+
+// DefaultChainConfig returns the consensus parameters used on the main
+// network.
+func DefaultChainConfig() ChainConfig {
+	return ChainConfig{
+		BlockFrequency:        BlockFrequency,
+		TargetWindow:          TargetWindow,
+		MedianTimestampWindow: MedianTimestampWindow,
+		FutureThreshold:       FutureThreshold,
+		MaxAdjustmentUp:       MaxAdjustmentUp,
+		MaxAdjustmentDown:     MaxAdjustmentDown,
+		SurpassThreshold:      big.NewRat(5, 100),
+	}
+}
+
+// nodeIndex is the subset of BlockIndex that standardValidator needs in
+// order to walk parents when computing timestamp rules.
+type nodeIndex interface {
+	Node(BlockID) (*BlockNode, error)
+}
+
+// standardValidator is the BlockValidator used by production States: the
+// same rules this package has always enforced, reachable through the
+// interface instead of hard-wired into State's methods.
+type standardValidator struct {
+	config ChainConfig
+	index  nodeIndex
+}
+
+// NewStandardValidator returns the default BlockValidator, backed by config
+// and able to walk parents through index.
+func NewStandardValidator(config ChainConfig, index nodeIndex) BlockValidator {
+	return &standardValidator{config: config, index: index}
+}
+
+// VerifyPoW implements the BlockValidator interface.
+func (v *standardValidator) VerifyPoW(b *Block, target Target) error {
+	if !b.CheckTarget(target) {
+		return ErrInvalidPoW
+	}
+	return nil
+}
+
+// ValidateHeader implements the BlockValidator interface.
+func (v *standardValidator) ValidateHeader(parent *BlockNode, b *Block) error {
+	if v.earliestChildTimestamp(parent) > b.Timestamp {
+		return ErrOldTimestamp
+	}
+	return nil
+}
+
+// ValidateBody implements the BlockValidator interface.
+func (v *standardValidator) ValidateBody(b *Block) error {
+	if b.MerkleRoot != b.TransactionMerkleRoot() {
+		return ErrMerkleMismatch
+	}
+	return nil
+}
+
+// earliestChildTimestamp returns the earliest timestamp that a child of bn
+// can have while still being valid. See section 'Timestamp Rules' in
+// Consensus.md. It takes the median of the timestamps of bn and up to
+// MedianTimestampWindow-1 of its ancestors, crawling parents through the
+// index rather than reading a preloaded window of timestamps, so that a
+// BlockNode never has to carry more than its own timestamp.
+func (v *standardValidator) earliestChildTimestamp(bn *BlockNode) Timestamp {
+	var intTimestamps []int
+	current := bn
+	for i := 0; i < v.config.MedianTimestampWindow; i++ {
+		intTimestamps = append(intTimestamps, int(current.Timestamp))
+		if current.Height == 0 {
+			break
+		}
+		parent, err := v.index.Node(current.ParentBlockID)
+		if err != nil {
+			// Should never happen: every non-genesis node's parent is in
+			// the index by construction. Stop crawling rather than panic.
+			break
+		}
+		current = parent
+	}
+	sort.Ints(intTimestamps)
+	return Timestamp(intTimestamps[len(intTimestamps)/2])
+}
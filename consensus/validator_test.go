@@ -0,0 +1,59 @@
+package consensus
+
+import "testing"
+
+// fakeNodeIndex is a minimal nodeIndex backed by a plain map, letting
+// standardValidator be tested without a full BlockIndex/ChainStore.
+type fakeNodeIndex map[BlockID]*BlockNode
+
+func (f fakeNodeIndex) Node(id BlockID) (*BlockNode, error) {
+	bn, exists := f[id]
+	if !exists {
+		return nil, ErrUnknownAncestor
+	}
+	return bn, nil
+}
+
+// TestStandardValidatorEarliestChildTimestamp verifies that
+// earliestChildTimestamp crawls parents through the index, rather than
+// reading a preloaded window, to find the median of the last
+// MedianTimestampWindow timestamps.
+func TestStandardValidatorEarliestChildTimestamp(t *testing.T) {
+	index := fakeNodeIndex{}
+	genesisID := BlockID{0}
+	index[genesisID] = &BlockNode{Height: 0, Timestamp: 100}
+
+	parentID := BlockID{1}
+	parent := &BlockNode{ParentBlockID: genesisID, Height: 1, Timestamp: 200}
+	index[parentID] = parent
+
+	config := DefaultChainConfig()
+	config.MedianTimestampWindow = 2
+	v := NewStandardValidator(config, index).(*standardValidator)
+
+	// intTimestamps = [200, 100] -> sorted [100, 200] -> index len/2 = 200.
+	if got := v.earliestChildTimestamp(parent); got != 200 {
+		t.Fatalf("expected earliest child timestamp 200, got %d", got)
+	}
+}
+
+// TestStandardValidatorValidateHeader verifies that ValidateHeader rejects a
+// block timestamped earlier than its parent's earliest allowed child
+// timestamp, and accepts one timestamped exactly at it.
+func TestStandardValidatorValidateHeader(t *testing.T) {
+	index := fakeNodeIndex{}
+	parentID := BlockID{1}
+	parent := &BlockNode{Height: 0, Timestamp: 1000}
+	index[parentID] = parent
+
+	config := DefaultChainConfig()
+	config.MedianTimestampWindow = 1
+	v := NewStandardValidator(config, index)
+
+	if err := v.ValidateHeader(parent, &Block{Timestamp: 999}); err != ErrOldTimestamp {
+		t.Fatalf("expected ErrOldTimestamp for a block older than its parent's earliest child timestamp, got %v", err)
+	}
+	if err := v.ValidateHeader(parent, &Block{Timestamp: 1000}); err != nil {
+		t.Fatalf("expected a block timestamped at the earliest allowed timestamp to validate, got %v", err)
+	}
+}